@@ -0,0 +1,136 @@
+package protolog
+
+import (
+	"fmt"
+	"io"
+)
+
+// ErrFrameTooLarge is returned by a ChunkedReader when the reassembled size
+// of a logical record would exceed the configured ChunkedReadLimit.
+var ErrFrameTooLarge = fmt.Errorf("frame exceeds configured read limit")
+
+// DefaultMaxFrameSize is the frame size used by a ChunkedWriter when
+// MaxFrameSize is left at its zero value.
+const DefaultMaxFrameSize = 16 * 1024 * 1024
+
+// Frame continuation flags are packed into the two high bits of the on-wire
+// typeID, leaving the low 14 bits for the caller's actual type ID. This
+// keeps the existing 10-byte header format unchanged for chunked records.
+const (
+	flagBits  = 2
+	flagShift = 16 - flagBits
+	flagMask  = uint16(0x3) << flagShift
+	typeMask  = ^flagMask
+
+	frameSolo   uint16 = 0 << flagShift // a complete, unchunked logical record
+	frameFirst  uint16 = 1 << flagShift // the first frame of a chunked record
+	frameMiddle uint16 = 2 << flagShift // an interior frame of a chunked record
+	frameLast   uint16 = 3 << flagShift // the final frame of a chunked record
+)
+
+// ChunkedWriter splits a single logical record into one or more fixed-size
+// frames, each a regular protolog record carrying its own CRC-32C, so that
+// no single frame exceeds the configured size and very large records can be
+// read back over a stream such as a pipe or socket. Write takes one whole
+// logical record per call; a record cannot be written in parts across
+// several Write calls.
+//
+// Because the continuation flag is packed into the record header's typeID
+// field, a ChunkedWriter's record type is limited to 14 bits (0-16383):
+// NewChunkedWriter rejects a recordType that sets either of the top two
+// bits.
+type ChunkedWriter struct {
+	typeID       uint16
+	w            io.Writer
+	maxFrameSize int
+}
+
+// NewChunkedWriter returns a new ChunkedWriter that splits records into
+// frames of at most MaxFrameSize bytes. A zero MaxFrameSize uses
+// DefaultMaxFrameSize.
+func NewChunkedWriter(recordType uint16, w io.Writer, maxFrameSize int) (*ChunkedWriter, error) {
+	if recordType&flagMask != 0 {
+		return nil, fmt.Errorf("record type %d uses reserved high bits", recordType)
+	}
+	if maxFrameSize == 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	return &ChunkedWriter{
+		typeID:       recordType,
+		w:            w,
+		maxFrameSize: maxFrameSize,
+	}, nil
+}
+
+// Write splits data into one or more frames and writes each as a record,
+// returning the total number of bytes written across all frames including
+// their headers.
+func (w *ChunkedWriter) Write(data []byte) (int, error) {
+	if len(data) <= w.maxFrameSize {
+		return writeRecord(w.w, w.typeID|frameSolo, data)
+	}
+	total := 0
+	for offset := 0; offset < len(data); offset += w.maxFrameSize {
+		end := offset + w.maxFrameSize
+		if end > len(data) {
+			end = len(data)
+		}
+		flag := frameMiddle
+		switch {
+		case offset == 0:
+			flag = frameFirst
+		case end == len(data):
+			flag = frameLast
+		}
+		n, err := writeRecord(w.w, w.typeID|flag, data[offset:end])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// ChunkedReader reassembles records written by a ChunkedWriter, transparently
+// joining FIRST/MIDDLE/LAST frames into a single logical record.
+type ChunkedReader struct {
+	r *Reader
+
+	// ChunkedReadLimit bounds the total reassembled size of a logical
+	// record. A zero value means no limit beyond available memory.
+	ChunkedReadLimit int64
+
+	buf []byte
+}
+
+// NewChunkedReader returns a new ChunkedReader reading frames from r.
+func NewChunkedReader(r io.Reader) *ChunkedReader {
+	return &ChunkedReader{r: NewReader(r)}
+}
+
+// Next returns the next reassembled logical record's type ID and data. It
+// returns io.EOF if there are no more records, and ErrFrameTooLarge if
+// reassembling the record would exceed ChunkedReadLimit.
+func (r *ChunkedReader) Next() (uint16, []byte, error) {
+	r.buf = r.buf[:0]
+	for {
+		wireType, data, err := r.r.Next()
+		if err != nil {
+			return 0, nil, err
+		}
+		flag := wireType & flagMask
+		typeID := wireType & typeMask
+
+		if flag == frameSolo || flag == frameFirst {
+			r.buf = r.buf[:0]
+		}
+		if r.ChunkedReadLimit > 0 && int64(len(r.buf)+len(data)) > r.ChunkedReadLimit {
+			return 0, nil, ErrFrameTooLarge
+		}
+		r.buf = append(r.buf, data...)
+
+		if flag == frameSolo || flag == frameLast {
+			return typeID, r.buf, nil
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package protolog
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestChunkedSingleFrame(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	w, err := NewChunkedWriter(BazTypeID, buf, 0)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	r := NewChunkedReader(buf)
+	typeID, data, err := r.Next()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if typeID != BazTypeID {
+		t.Fatalf("expected type ID %d, got %d", BazTypeID, typeID)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("expected %q, got %q", "hello", data)
+	}
+	if _, _, err := r.Next(); err != io.EOF {
+		t.Fatalf("got %v, expected %v", err, io.EOF)
+	}
+}
+
+func TestChunkedMultiFrame(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 1000) // 10,000 bytes
+
+	buf := new(bytes.Buffer)
+	w, err := NewChunkedWriter(FooTypeID, buf, 100)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := w.Write(payload); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	r := NewChunkedReader(buf)
+	typeID, data, err := r.Next()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if typeID != FooTypeID {
+		t.Fatalf("expected type ID %d, got %d", FooTypeID, typeID)
+	}
+	if !bytes.Equal(data, payload) {
+		t.Fatalf("reassembled data did not match original payload")
+	}
+}
+
+func TestChunkedReadLimit(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 300)
+
+	buf := new(bytes.Buffer)
+	w, err := NewChunkedWriter(FooTypeID, buf, 100)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := w.Write(payload); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	r := NewChunkedReader(buf)
+	r.ChunkedReadLimit = 200
+	if _, _, err := r.Next(); err != ErrFrameTooLarge {
+		t.Fatalf("got %v, expected %v", err, ErrFrameTooLarge)
+	}
+}
+
+func TestNewChunkedWriterRejectsReservedBits(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if _, err := NewChunkedWriter(0xC000, buf, 0); err == nil {
+		t.Fatalf("expected error for type ID using reserved high bits")
+	}
+}
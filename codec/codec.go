@@ -0,0 +1,58 @@
+// Package codec defines the pluggable compression interface that protolog
+// applies to record payloads when a file is written with a compression
+// codec selected, plus a registry so a reader can resolve the codec named
+// in a file's preamble without the caller hard-coding every implementation
+// it might encounter.
+package codec
+
+import "fmt"
+
+// Codec compresses and decompresses record payloads. Implementations must
+// be safe for concurrent use, since a single Codec may be shared by
+// multiple readers or writers.
+type Codec interface {
+	// ID is the single byte written to a file's preamble to identify this
+	// codec so a reader can resolve it via ByID.
+	ID() byte
+	// Name is a short human-readable identifier, e.g. "zstd".
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// None is the identity codec: Compress and Decompress return data
+// unchanged. It is always registered under ID 0.
+var None Codec = noneCodec{}
+
+type noneCodec struct{}
+
+func (noneCodec) ID() byte                               { return 0 }
+func (noneCodec) Name() string                           { return "none" }
+func (noneCodec) Compress(data []byte) ([]byte, error)   { return data, nil }
+func (noneCodec) Decompress(data []byte) ([]byte, error) { return data, nil }
+
+var registry = map[byte]Codec{
+	None.ID(): None,
+}
+
+// Register adds c to the set of codecs resolvable by ByID, keyed by
+// c.ID(). Codec implementations register themselves from their own init(),
+// following the same pattern as image format or database/sql drivers: a
+// caller imports a codec package for its side effect and then selects it
+// by ID or name.
+func Register(c Codec) {
+	registry[c.ID()] = c
+}
+
+// ByID returns the registered codec for id, or false if none has been
+// registered (typically because the codec's package was never imported).
+func ByID(id byte) (Codec, bool) {
+	c, ok := registry[id]
+	return c, ok
+}
+
+// ErrUnknownCodec is returned when a file's preamble names a codec ID that
+// has not been registered.
+func ErrUnknownCodec(id byte) error {
+	return fmt.Errorf("codec: no codec registered for id %d (forgot to import its package?)", id)
+}
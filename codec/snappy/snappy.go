@@ -0,0 +1,33 @@
+// Package snappy registers a protolog codec.Codec backed by Snappy.
+// Importing this package for its side effect makes codec ID 2 ("snappy")
+// available to protolog readers and writers.
+package snappy
+
+import (
+	"github.com/golang/snappy"
+
+	"github.com/DocSavage/protolog/codec"
+)
+
+func init() {
+	codec.Register(Codec)
+}
+
+// ID is the codec.Codec.ID() written to a file preamble to select snappy.
+const ID byte = 2
+
+// Codec is the Snappy-backed codec.Codec, shared by all callers.
+var Codec codec.Codec = snappyCodec{}
+
+type snappyCodec struct{}
+
+func (snappyCodec) ID() byte     { return ID }
+func (snappyCodec) Name() string { return "snappy" }
+
+func (snappyCodec) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (snappyCodec) Decompress(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
@@ -0,0 +1,45 @@
+// Package zstd registers a protolog codec.Codec backed by zstd. Importing
+// this package for its side effect makes codec ID 1 ("zstd") available to
+// protolog readers and writers.
+package zstd
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/DocSavage/protolog/codec"
+)
+
+func init() {
+	codec.Register(Codec)
+}
+
+// ID is the codec.Codec.ID() written to a file preamble to select zstd.
+const ID byte = 1
+
+// Codec is the zstd-backed codec.Codec, shared by all callers.
+var Codec codec.Codec = zstdCodec{}
+
+type zstdCodec struct{}
+
+func (zstdCodec) ID() byte     { return ID }
+func (zstdCodec) Name() string { return "zstd" }
+
+func (zstdCodec) Compress(data []byte) ([]byte, error) {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, fmt.Errorf("zstd: creating encoder: %w", err)
+	}
+	defer enc.Close()
+	return enc.EncodeAll(data, nil), nil
+}
+
+func (zstdCodec) Decompress(data []byte) ([]byte, error) {
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, fmt.Errorf("zstd: creating decoder: %w", err)
+	}
+	defer dec.Close()
+	return dec.DecodeAll(data, nil)
+}
@@ -0,0 +1,176 @@
+package protolog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/DocSavage/protolog/codec"
+	"github.com/DocSavage/protolog/typemeta"
+)
+
+// fileMagic marks a protolog file that begins with a Preamble rather than
+// going straight into records. Its presence as the first four bytes is
+// what lets a pre-preamble reader reject such a file instead of silently
+// misinterpreting the preamble as a corrupt record.
+var fileMagic = [4]byte{'P', 'L', 'G', '2'}
+
+const preambleVersion uint8 = 1
+
+// Option configures a Writer constructed by NewWriter.
+type Option func(*Writer)
+
+// WithCompression selects the codec applied to every record payload. The
+// chosen codec's ID is written to the file preamble so that NewReader can
+// resolve the same codec via codec.ByID, provided the reader's process has
+// imported that codec's package. The default is codec.None.
+func WithCompression(c codec.Codec) Option {
+	return func(w *Writer) { w.codec = c }
+}
+
+// RegisterType adds msg's fully-qualified name (and descriptor, if
+// available) to the file's type-descriptor table under typeID, so readers
+// can resolve it via Reader.Descriptor without a hard-coded type registry.
+func RegisterType(typeID uint16, msg proto.Message) Option {
+	return func(w *Writer) { w.types.Register(typeID, msg) }
+}
+
+// Writer writes records preceded by a file-level Preamble declaring an
+// optional compression codec and a type-descriptor table. Unlike
+// TypedWriter and MultiTypedWriter, a Writer must be constructed with
+// NewWriter so its preamble is written before any records.
+type Writer struct {
+	w     io.Writer
+	codec codec.Codec
+	types *typemeta.Table
+}
+
+// NewWriter writes a Preamble to w reflecting opts, then returns a Writer
+// for the records that follow.
+func NewWriter(w io.Writer, opts ...Option) (*Writer, error) {
+	pw := &Writer{
+		w:     w,
+		codec: codec.None,
+		types: typemeta.NewTable(),
+	}
+	for _, opt := range opts {
+		opt(pw)
+	}
+	if err := writePreamble(w, pw.codec, pw.types); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// Write compresses data with the Writer's codec and writes it as a record
+// with the given type ID.
+func (w *Writer) Write(typeID uint16, data []byte) (int, error) {
+	compressed, err := w.codec.Compress(data)
+	if err != nil {
+		return 0, fmt.Errorf("compressing record: %w", err)
+	}
+	return writeRecord(w.w, typeID, compressed)
+}
+
+func writePreamble(w io.Writer, c codec.Codec, types *typemeta.Table) error {
+	if _, err := w.Write(fileMagic[:]); err != nil {
+		return fmt.Errorf("writing file magic: %w", err)
+	}
+	hdr := []byte{preambleVersion, c.ID()}
+	if _, err := w.Write(hdr); err != nil {
+		return fmt.Errorf("writing preamble header: %w", err)
+	}
+
+	entries := types.Entries()
+	countBuf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(countBuf, uint32(len(entries)))
+	if _, err := w.Write(countBuf); err != nil {
+		return fmt.Errorf("writing type table count: %w", err)
+	}
+	for _, e := range entries {
+		fdBytes, err := proto.Marshal(e.FD)
+		if err != nil {
+			return fmt.Errorf("marshaling descriptor for type %d: %w", e.TypeID, err)
+		}
+		entryHdr := make([]byte, 2+2+4)
+		binary.LittleEndian.PutUint16(entryHdr[0:2], e.TypeID)
+		binary.LittleEndian.PutUint16(entryHdr[2:4], uint16(len(e.Name)))
+		binary.LittleEndian.PutUint32(entryHdr[4:8], uint32(len(fdBytes)))
+		if _, err := w.Write(entryHdr); err != nil {
+			return fmt.Errorf("writing type table entry %d: %w", e.TypeID, err)
+		}
+		if _, err := io.WriteString(w, e.Name); err != nil {
+			return fmt.Errorf("writing type name for %d: %w", e.TypeID, err)
+		}
+		if _, err := w.Write(fdBytes); err != nil {
+			return fmt.Errorf("writing descriptor for %d: %w", e.TypeID, err)
+		}
+	}
+	return nil
+}
+
+// readPreamble reads a Preamble from br if one is present, signalled by
+// fileMagic as the first four bytes. If fileMagic isn't present, br is left
+// untouched (via Peek) and readPreamble returns a nil codec and types,
+// meaning the file has no preamble.
+func readPreamble(br *bufio.Reader) (codec.Codec, *typemeta.Table, error) {
+	peeked, err := br.Peek(len(fileMagic))
+	if err != nil || [4]byte{peeked[0], peeked[1], peeked[2], peeked[3]} != fileMagic {
+		return nil, nil, nil
+	}
+	if _, err := br.Discard(len(fileMagic)); err != nil {
+		return nil, nil, err
+	}
+
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return nil, nil, fmt.Errorf("reading preamble header: %w", err)
+	}
+	version, codecID := hdr[0], hdr[1]
+	if version != preambleVersion {
+		return nil, nil, fmt.Errorf("unsupported preamble version %d", version)
+	}
+	c, ok := codec.ByID(codecID)
+	if !ok {
+		return nil, nil, codec.ErrUnknownCodec(codecID)
+	}
+
+	countBuf := make([]byte, 4)
+	if _, err := io.ReadFull(br, countBuf); err != nil {
+		return nil, nil, fmt.Errorf("reading type table count: %w", err)
+	}
+	count := binary.LittleEndian.Uint32(countBuf)
+
+	types := typemeta.NewTable()
+	for i := uint32(0); i < count; i++ {
+		entryHdr := make([]byte, 8)
+		if _, err := io.ReadFull(br, entryHdr); err != nil {
+			return nil, nil, fmt.Errorf("reading type table entry: %w", err)
+		}
+		typeID := binary.LittleEndian.Uint16(entryHdr[0:2])
+		nameLen := binary.LittleEndian.Uint16(entryHdr[2:4])
+		fdLen := binary.LittleEndian.Uint32(entryHdr[4:8])
+
+		nameBuf := make([]byte, nameLen)
+		if _, err := io.ReadFull(br, nameBuf); err != nil {
+			return nil, nil, fmt.Errorf("reading type name for %d: %w", typeID, err)
+		}
+		fdBuf := make([]byte, fdLen)
+		if _, err := io.ReadFull(br, fdBuf); err != nil {
+			return nil, nil, fmt.Errorf("reading descriptor for %d: %w", typeID, err)
+		}
+		var fd *descriptorpb.FileDescriptorProto
+		if fdLen > 0 {
+			fd = &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(fdBuf, fd); err != nil {
+				return nil, nil, fmt.Errorf("unmarshaling descriptor for %d: %w", typeID, err)
+			}
+		}
+		types.AddEntry(typeID, string(nameBuf), fd)
+	}
+	return c, types, nil
+}
@@ -0,0 +1,144 @@
+package protolog
+
+import (
+	"bytes"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/DocSavage/protolog/codec"
+	"github.com/DocSavage/protolog/codec/snappy"
+	"github.com/DocSavage/protolog/codec/zstd"
+)
+
+func TestWriterNoPreambleOptions(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	w, err := NewWriter(buf)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := w.Write(FooTypeID, []byte("hello")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	r := NewReader(buf)
+	typeID, data, err := r.Next()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if typeID != FooTypeID {
+		t.Fatalf("expected type ID %d, got %d", FooTypeID, typeID)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("expected %q, got %q", "hello", data)
+	}
+}
+
+func TestReaderWithNoPreambleStillWorks(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewTypedWriter(BazTypeID, buf)
+	if _, err := w.Write([]byte("plain")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	r := NewReader(buf)
+	typeID, data, err := r.Next()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if typeID != BazTypeID || string(data) != "plain" {
+		t.Fatalf("got (%d, %q), expected (%d, %q)", typeID, data, BazTypeID, "plain")
+	}
+}
+
+func TestWriterCompressionRoundTrip(t *testing.T) {
+	for _, c := range []codec.Codec{zstd.Codec, snappy.Codec} {
+		t.Run(c.Name(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w, err := NewWriter(buf, WithCompression(c))
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			// A repetitive payload so a broken codec that forgets to
+			// compress (or decompress) is still caught by the roundtrip.
+			want := bytes.Repeat([]byte("hello protolog "), 100)
+			if _, err := w.Write(FooTypeID, want); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			r := NewReader(buf)
+			typeID, data, err := r.Next()
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if typeID != FooTypeID {
+				t.Fatalf("expected type ID %d, got %d", FooTypeID, typeID)
+			}
+			if !bytes.Equal(data, want) {
+				t.Fatalf("got %q, expected %q", data, want)
+			}
+		})
+	}
+}
+
+func TestReaderDescriptorResolvesRegisteredType(t *testing.T) {
+	buf := new(bytes.Buffer)
+	msg := wrapperspb.String("hello descriptor")
+	w, err := NewWriter(buf, RegisterType(FooTypeID, msg))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := w.Write(FooTypeID, payload); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	r := NewReader(buf)
+	typeID, data, err := r.Next()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	mt, ok := r.Descriptor(typeID)
+	if !ok {
+		t.Fatalf("expected a descriptor for type %d", typeID)
+	}
+	got := mt.New().Interface()
+	if err := proto.Unmarshal(data, got); err != nil {
+		t.Fatalf("unexpected error unmarshaling with resolved descriptor: %v", err)
+	}
+	// got is a dynamicpb message built from the rebuilt FileDescriptorProto,
+	// not a *wrapperspb.StringValue, so compare the marshaled bytes rather
+	// than proto.Equal (which requires both sides to share the exact same
+	// Descriptor, which a dynamic and a generated message never do).
+	gotBytes, err := proto.Marshal(got)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !bytes.Equal(gotBytes, payload) {
+		t.Fatalf("got %q, expected %q", gotBytes, payload)
+	}
+}
+
+func TestReaderDescriptorUnknownTypeIDFalse(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w, err := NewWriter(buf)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := w.Write(FooTypeID, []byte("hi")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	r := NewReader(buf)
+	if _, _, err := r.Next(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, ok := r.Descriptor(FooTypeID); ok {
+		t.Fatalf("expected no descriptor for an unregistered type")
+	}
+}
@@ -5,17 +5,26 @@
 // trivial.  This design is a modified form of Eric Lesh's recordio Go
 // implementation (github.com/eclesh/recordio).  It uses fixed size headers
 // with support for a uint16 ID of the message type and a CRC-32C checksum.
-// Each blob must be less than 4 GiB (2^32 bytes).
+// Each blob must be less than 4 GiB (2^32 bytes). ChunkedWriter and
+// ChunkedReader are the one exception to the full 16-bit type ID range:
+// they steal its two high bits for a frame-continuation flag, so a type ID
+// used with them is limited to 14 bits (0-16383).
 package protolog
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"hash/crc32"
 	"io"
 	"log"
 	"math"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/DocSavage/protolog/codec"
+	"github.com/DocSavage/protolog/typemeta"
 )
 
 var (
@@ -29,6 +38,11 @@ type Reader struct {
 	buf    []byte    // the buffer
 	bufcap uint32    // the capacity of the buffer
 	hdr    header    // the header
+
+	initialized bool
+	initErr     error
+	codec       codec.Codec
+	types       *typemeta.Table
 }
 
 type header struct {
@@ -73,18 +87,45 @@ func writeRecord(w io.Writer, typeID uint16, data []byte) (int, error) {
 	return 10 + int(numBytes), err
 }
 
-// NewReader returns a new reader. If r doesn't implement
-// io.ByteReader, it will be wrapped in a bufio.Reader.
+// NewReader returns a new reader, always wrapped in a bufio.Reader so that
+// a leading Preamble (written by NewWriter) can be detected and consumed
+// before the first record is read.
 func NewReader(r io.Reader) *Reader {
-	if _, ok := r.(io.ByteReader); !ok {
-		r = bufio.NewReader(r)
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Reader{r: br}
+}
+
+// init consumes a leading Preamble, if present, the first time Next is
+// called. A file with no preamble is left untouched and decompresses as a
+// no-op, so NewReader continues to work on files with no preamble.
+func (r *Reader) init() error {
+	if r.initialized {
+		return r.initErr
+	}
+	r.initialized = true
+	c, types, err := readPreamble(r.r.(*bufio.Reader))
+	if err != nil {
+		r.initErr = err
+		return err
+	}
+	if c == nil {
+		c = codec.None
 	}
-	return &Reader{r: r}
+	r.codec = c
+	r.types = types
+	return nil
 }
 
-// Next returns the next data record's type ID (if set) and data.
-// It returns io.EOF if there are no more records.
+// Next returns the next data record's type ID (if set) and data,
+// transparently decompressing it if the file declared a compression codec
+// in its preamble. It returns io.EOF if there are no more records.
 func (r *Reader) Next() (uint16, []byte, error) {
+	if err := r.init(); err != nil {
+		return 0, nil, err
+	}
 	hdr, err := readHeader(r.r)
 	if err != nil {
 		return 0, nil, err
@@ -101,9 +142,43 @@ func (r *Reader) Next() (uint16, []byte, error) {
 	if checksum != hdr.checksum {
 		return 0, nil, ErrBadChecksum
 	}
-	return hdr.typeID, r.buf[:hdr.numBytes], nil
+	data, err := r.codec.Decompress(r.buf[:hdr.numBytes])
+	if err != nil {
+		return 0, nil, fmt.Errorf("decompressing record: %w", err)
+	}
+	return hdr.typeID, data, nil
+}
+
+// Descriptor returns the protoreflect.MessageType registered for typeID in
+// the file's preamble type-descriptor table, if the file had one and
+// registered typeID with a FileDescriptorProto. It lets callers
+// dynamically unmarshal a record's payload without a hard-coded type
+// registry. Nested message types are not resolved; see
+// typemeta.Table.Descriptor.
+func (r *Reader) Descriptor(typeID uint16) (protoreflect.MessageType, bool) {
+	if err := r.init(); err != nil || r.types == nil {
+		return nil, false
+	}
+	return r.types.Descriptor(typeID)
+}
+
+// Range identifies a contiguous byte range, as an offset and length from
+// the start of the scanned stream, that a recoverable Scanner skipped over
+// while resynchronizing after corruption.
+type Range struct {
+	Offset int64
+	Length int64
 }
 
+// recoverPeekSize bounds how large a *candidate* record's declared length
+// may be while recover is byte-scanning for the next plausible header
+// after corruption, so that validating each of many candidates (most of
+// which are garbage) can't each demand an arbitrarily large read or
+// allocation. It does not apply to Scan's normal, trusted read of the
+// record at the expected position, which honors the full 4 GiB records are
+// otherwise allowed to be; see readTrusted.
+const recoverPeekSize = 1 << 20 // 1 MiB
+
 // A Scanner is a convenient method for reading records sequentially.
 type Scanner struct {
 	r       io.Reader // the reader
@@ -112,25 +187,90 @@ type Scanner struct {
 	bufsize uint32
 	bufcap  uint32
 	hdr     *header
+
+	pos         int64
+	recoverable bool
+	skipped     []Range
+
+	// SkipHandler, if set, is called with the offset and length of every
+	// byte range skipped while resynchronizing in recover mode.
+	SkipHandler func(offset, length int64)
 }
 
-// NewScanner creates a new Scanner from reader r.
+// NewScanner creates a new Scanner from reader r. r is always wrapped in a
+// bufio.Reader so that recover mode can use Peek to look for the next
+// plausible header without losing unread bytes.
 func NewScanner(r io.Reader) *Scanner {
-	if _, ok := r.(io.ByteReader); !ok {
-		r = bufio.NewReader(r)
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReaderSize(r, 10+recoverPeekSize)
+	}
+	return &Scanner{r: br}
+}
+
+// SetRecoverable enables or disables recover mode. In recover mode, a
+// header read error or ErrBadChecksum no longer ends the scan; instead
+// Scan searches forward byte-by-byte for the next header whose declared
+// length and CRC-32C both check out, and resumes from there, the way the
+// etcd WAL resumes after a "log truncated/corrupted at N" event. The
+// skipped range is reported through SkipHandler and SkippedRanges.
+func (s *Scanner) SetRecoverable(recoverable bool) {
+	s.recoverable = recoverable
+}
+
+// SkippedRanges returns the byte ranges skipped so far while
+// resynchronizing in recover mode.
+func (s *Scanner) SkippedRanges() []Range {
+	return s.skipped
+}
+
+func (s *Scanner) recordSkip(offset, length int64) {
+	if length <= 0 {
+		return
+	}
+	s.skipped = append(s.skipped, Range{Offset: offset, Length: length})
+	if s.SkipHandler != nil {
+		s.SkipHandler(offset, length)
 	}
-	return &Scanner{r: r}
 }
 
 // Scan chugs through the input record by record and stops at the first
-// error or EOF.
+// error or EOF. If recover mode is enabled, a corrupt record instead causes
+// Scan to resynchronize on the next plausible record and continue. In
+// recover mode, Scan first makes one trusted, uncapped attempt to read the
+// record at the expected position (readTrusted); only once that attempt
+// turns out to be corrupt does it fall back to a bounded, speculative
+// byte-by-byte search (recover/tryScanAt) for the next plausible header.
 func (s *Scanner) Scan() bool {
+	recordStart := s.pos
+	if s.recoverable {
+		br, ok := s.r.(*bufio.Reader)
+		if !ok {
+			br = bufio.NewReaderSize(s.r, 10+recoverPeekSize)
+			s.r = br
+		}
+		ok, retained := s.readTrusted(br)
+		if ok {
+			return true
+		}
+		if retained == nil {
+			s.err = io.EOF
+			return false
+		}
+		// retained holds every byte readTrusted consumed while it couldn't
+		// yet tell the record was corrupt; feed it back in front of br so
+		// recover can search it instead of treating it as lost.
+		s.r = bufio.NewReaderSize(io.MultiReader(bytes.NewReader(retained), br), 10+recoverPeekSize)
+		return s.recover(recordStart)
+	}
+
 	var err error
 	s.hdr, err = readHeader(s.r)
 	if err != nil {
 		s.err = err
 		return false
 	}
+	s.pos += 10
 	s.bufsize = s.hdr.numBytes
 	if s.hdr.numBytes > s.bufcap {
 		s.buf = make([]byte, s.hdr.numBytes)
@@ -141,6 +281,7 @@ func (s *Scanner) Scan() bool {
 		s.err = err
 		return false
 	}
+	s.pos += int64(s.hdr.numBytes)
 	checksum := crc32.Checksum(s.buf[:s.hdr.numBytes], checksumTable)
 	if checksum != s.hdr.checksum {
 		log.Printf("expected %d, got %d\n", s.hdr.checksum, checksum)
@@ -150,6 +291,116 @@ func (s *Scanner) Scan() bool {
 	return true
 }
 
+// readTrusted makes one uncapped attempt to read the record at br's current
+// position, trusting its declared length the way the non-recoverable path
+// does, so a legitimately large record is never skipped just because
+// recover mode happens to be on. It reads (rather than peeks) the header
+// and payload, since peeking a multi-GiB candidate isn't practical; every
+// byte it reads is returned via retained whenever the candidate doesn't pan
+// out, so the caller can hand it to recover instead of losing it. ok
+// reports whether a valid record was read (and applied to the Scanner);
+// retained is nil only when the stream was cleanly exhausted before
+// anything at all could be read.
+func (s *Scanner) readTrusted(br *bufio.Reader) (ok bool, retained []byte) {
+	hdrbuf := make([]byte, 10)
+	n, err := io.ReadFull(br, hdrbuf)
+	if n == 0 && err != nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, hdrbuf[:n]
+	}
+	numBytes := binary.LittleEndian.Uint32(hdrbuf[0:4])
+	checksum := binary.LittleEndian.Uint32(hdrbuf[4:8])
+	typeID := binary.LittleEndian.Uint16(hdrbuf[8:10])
+
+	payload := make([]byte, numBytes)
+	pn, err := io.ReadFull(br, payload)
+	if err != nil {
+		return false, append(hdrbuf, payload[:pn]...)
+	}
+	if crc32.Checksum(payload, checksumTable) != checksum {
+		return false, append(hdrbuf, payload...)
+	}
+
+	s.pos += int64(10 + numBytes)
+	s.hdr = &header{numBytes: numBytes, checksum: checksum, typeID: typeID}
+	s.bufsize = numBytes
+	if numBytes > s.bufcap {
+		s.buf = make([]byte, numBytes)
+		s.bufcap = numBytes
+	}
+	copy(s.buf[:numBytes], payload)
+	return true, nil
+}
+
+// tryScanAt peeks the header and, if it looks plausible, the payload at the
+// reader's current position without consuming anything. It only discards
+// the bytes once the declared length is within recoverPeekSize and the
+// CRC-32C of the peeked payload matches, so a candidate that turns out to
+// be corrupt or garbage leaves the stream untouched for the caller to
+// advance and retry one byte later. ok reports whether a valid record was
+// found (and consumed) at the current position; eof reports whether the
+// stream is cleanly exhausted (no bytes left to try at all). Used only by
+// recover's speculative byte-by-byte search, where recoverPeekSize's cap on
+// a candidate's declared length is appropriate.
+func (s *Scanner) tryScanAt(br *bufio.Reader) (ok bool, eof bool) {
+	hdrbuf, err := br.Peek(10)
+	if err != nil {
+		return false, len(hdrbuf) == 0
+	}
+	numBytes := binary.LittleEndian.Uint32(hdrbuf[0:4])
+	checksum := binary.LittleEndian.Uint32(hdrbuf[4:8])
+	typeID := binary.LittleEndian.Uint16(hdrbuf[8:10])
+	if numBytes > recoverPeekSize {
+		return false, false
+	}
+	record, err := br.Peek(10 + int(numBytes))
+	if err != nil {
+		return false, false
+	}
+	if crc32.Checksum(record[10:], checksumTable) != checksum {
+		return false, false
+	}
+	if _, err := br.Discard(10 + int(numBytes)); err != nil {
+		return false, false
+	}
+	s.pos += int64(10 + numBytes)
+	s.hdr = &header{numBytes: numBytes, checksum: checksum, typeID: typeID}
+	s.bufsize = numBytes
+	if numBytes > s.bufcap {
+		s.buf = make([]byte, numBytes)
+		s.bufcap = numBytes
+	}
+	copy(s.buf[:numBytes], record[10:])
+	return true, false
+}
+
+// recover scans forward from start one byte at a time, via tryScanAt, until
+// it finds a candidate record whose declared length and CRC-32C both check
+// out, or until the stream is exhausted. start is the offset of the record
+// that triggered recovery, used to report the full skipped range rather
+// than just the bytes recover() itself had to step over.
+func (s *Scanner) recover(start int64) bool {
+	br := s.r.(*bufio.Reader)
+	for {
+		if _, err := br.Discard(1); err != nil {
+			s.recordSkip(start, s.pos-start)
+			s.err = io.ErrUnexpectedEOF
+			return false
+		}
+		s.pos++
+		if ok, eof := s.tryScanAt(br); ok {
+			s.recordSkip(start, s.pos-start)
+			return true
+		} else if eof {
+			s.recordSkip(start, s.pos-start)
+			s.err = io.ErrUnexpectedEOF
+			return false
+		}
+	}
+}
+
 // TypeID returns the optionally set type ID of the most recently scanned record.
 func (s *Scanner) TypeID() uint16 {
 	return s.hdr.typeID
@@ -0,0 +1,137 @@
+package protolog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func writeTestRecords(t *testing.T, values []string) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	w := NewMultiTypedWriter(buf)
+	for i, val := range values {
+		if _, err := w.Write(uint16(i), []byte(val)); err != nil {
+			t.Fatalf("unexpected error writing %q: %v", val, err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestScannerRecoverFromBitFlip(t *testing.T) {
+	values := []string{"first", "second", "third"}
+	data := writeTestRecords(t, values)
+
+	// Flip a bit in the middle record's data.
+	secondOffset := 10 + len(values[0]) + 10 + 2 // into "second"'s payload
+	data[secondOffset] ^= 0xFF
+
+	var skipped []Range
+	s := NewScanner(bytes.NewReader(data))
+	s.SetRecoverable(true)
+	s.SkipHandler = func(offset, length int64) {
+		skipped = append(skipped, Range{Offset: offset, Length: length})
+	}
+
+	var got []string
+	for s.Scan() {
+		got = append(got, string(s.Bytes()))
+	}
+	if err := s.Error(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(got) != 2 || got[0] != "first" || got[1] != "third" {
+		t.Fatalf("expected [first third], got %v", got)
+	}
+	if len(skipped) == 0 {
+		t.Fatalf("expected at least one skipped range")
+	}
+	if len(s.SkippedRanges()) != len(skipped) {
+		t.Fatalf("SkippedRanges() out of sync with SkipHandler calls")
+	}
+}
+
+func TestScannerRecoverFromInsertedGarbage(t *testing.T) {
+	values := []string{"first", "second", "third"}
+	data := writeTestRecords(t, values)
+
+	withGarbage := new(bytes.Buffer)
+	withGarbage.Write(data[:10+len(values[0])])
+	withGarbage.Write([]byte("garbage-not-a-record"))
+	withGarbage.Write(data[10+len(values[0]):])
+
+	s := NewScanner(bytes.NewReader(withGarbage.Bytes()))
+	s.SetRecoverable(true)
+
+	var got []string
+	for s.Scan() {
+		got = append(got, string(s.Bytes()))
+	}
+	if err := s.Error(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(got) != 3 || got[0] != "first" || got[1] != "second" || got[2] != "third" {
+		t.Fatalf("expected [first second third], got %v", got)
+	}
+}
+
+func TestScannerRecoverFromTruncatedTail(t *testing.T) {
+	values := []string{"first", "second"}
+	data := writeTestRecords(t, values)
+
+	truncated := data[:len(data)-3] // cut off inside the last record
+
+	s := NewScanner(bytes.NewReader(truncated))
+	s.SetRecoverable(true)
+
+	var got []string
+	for s.Scan() {
+		got = append(got, string(s.Bytes()))
+	}
+	if len(got) != 1 || got[0] != "first" {
+		t.Fatalf("expected [first], got %v", got)
+	}
+	// A truncated tail is a real error, not a clean EOF; Error() should
+	// report it rather than silently dropping the partial record.
+	if s.Error() == nil {
+		t.Fatalf("expected an error for a truncated tail, got nil")
+	}
+}
+
+func TestScannerRecoverableReadsLargeUncorruptedRecord(t *testing.T) {
+	big := bytes.Repeat([]byte("x"), 2<<20) // 2 MiB, bigger than recoverPeekSize
+	values := []string{string(big), "small"}
+	data := writeTestRecords(t, values)
+
+	s := NewScanner(bytes.NewReader(data))
+	s.SetRecoverable(true)
+
+	var got []int
+	for s.Scan() {
+		got = append(got, len(s.Bytes()))
+	}
+	if err := s.Error(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(got) != 2 || got[0] != len(big) || got[1] != len("small") {
+		t.Fatalf("expected [%d %d], got %v", len(big), len("small"), got)
+	}
+}
+
+func TestScannerNonRecoverableStopsAtCorruption(t *testing.T) {
+	values := []string{"first", "second"}
+	data := writeTestRecords(t, values)
+	data[10+len(values[0])+10+2] ^= 0xFF // flip a bit in "second"'s payload
+
+	s := NewScanner(bytes.NewReader(data))
+
+	var got []string
+	for s.Scan() {
+		got = append(got, string(s.Bytes()))
+	}
+	if len(got) != 1 || got[0] != "first" {
+		t.Fatalf("expected [first], got %v", got)
+	}
+	if s.Error() != ErrBadChecksum {
+		t.Fatalf("expected %v, got %v", ErrBadChecksum, s.Error())
+	}
+}
@@ -0,0 +1,320 @@
+package protolog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RecordPos identifies the on-disk location of a single record written by a
+// SegmentedWriter: the segment file it lives in and its byte offset within
+// that segment. It is opaque to callers beyond passing it back to a
+// RandomAccessReader or SegmentedReader.Truncate.
+type RecordPos struct {
+	Segment uint32
+	Offset  int64
+}
+
+const (
+	segmentFilePattern = "%06d.plog"
+	indexFileName      = "index"
+	indexEntrySize     = 12 // uint32 segment + int64 offset
+)
+
+func segmentPath(dir string, segment uint32) string {
+	return filepath.Join(dir, fmt.Sprintf(segmentFilePattern, segment))
+}
+
+// SegmentedWriter writes records into a directory of numbered segment
+// files (e.g. 000001.plog), rotating to a new segment once the current one
+// has reached the configured byte or record count threshold. Each Write also appends the
+// record's position to an on-disk index file, so a later reader can map a
+// logical record index back to a (segment, offset) without rescanning.
+type SegmentedWriter struct {
+	dir               string
+	maxSegmentBytes   int64
+	maxSegmentRecords int
+
+	mu         sync.Mutex
+	cur        *os.File
+	curSegment uint32
+	curBytes   int64
+	curRecords int
+	index      *os.File
+}
+
+// NewSegmentedWriter returns a new SegmentedWriter writing segments into
+// dir, rotating once a segment reaches maxSegmentBytes or maxSegmentRecords.
+// A zero threshold disables rotation on that dimension. dir must already
+// exist.
+func NewSegmentedWriter(dir string, maxSegmentBytes int64, maxSegmentRecords int) (*SegmentedWriter, error) {
+	index, err := os.OpenFile(filepath.Join(dir, indexFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening segment index: %w", err)
+	}
+	w := &SegmentedWriter{
+		dir:               dir,
+		maxSegmentBytes:   maxSegmentBytes,
+		maxSegmentRecords: maxSegmentRecords,
+		index:             index,
+	}
+	if err := w.openSegment(0); err != nil {
+		index.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *SegmentedWriter) openSegment(segment uint32) error {
+	f, err := os.OpenFile(segmentPath(w.dir, segment), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening segment %06d: %w", segment, err)
+	}
+	w.cur = f
+	w.curSegment = segment
+	w.curBytes = 0
+	w.curRecords = 0
+	return nil
+}
+
+func (w *SegmentedWriter) rotate() error {
+	if err := w.cur.Close(); err != nil {
+		return fmt.Errorf("closing segment %06d: %w", w.curSegment, err)
+	}
+	return w.openSegment(w.curSegment + 1)
+}
+
+// Write appends a record with the given type ID and returns the RecordPos
+// at which it was written, rotating to a new segment first if the current
+// one has reached its configured threshold.
+func (w *SegmentedWriter) Write(typeID uint16, data []byte) (RecordPos, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.curRecords > 0 &&
+		((w.maxSegmentBytes > 0 && w.curBytes >= w.maxSegmentBytes) ||
+			(w.maxSegmentRecords > 0 && w.curRecords >= w.maxSegmentRecords)) {
+		if err := w.rotate(); err != nil {
+			return RecordPos{}, err
+		}
+	}
+
+	pos := RecordPos{Segment: w.curSegment, Offset: w.curBytes}
+	n, err := writeRecord(w.cur, typeID, data)
+	if err != nil {
+		return RecordPos{}, err
+	}
+	w.curBytes += int64(n)
+	w.curRecords++
+
+	indexBuf := make([]byte, indexEntrySize)
+	binary.LittleEndian.PutUint32(indexBuf[0:4], pos.Segment)
+	binary.LittleEndian.PutUint64(indexBuf[4:12], uint64(pos.Offset))
+	if _, err := w.index.Write(indexBuf); err != nil {
+		return RecordPos{}, fmt.Errorf("appending to segment index: %w", err)
+	}
+	return pos, nil
+}
+
+// Sync flushes the current segment and index file to stable storage.
+func (w *SegmentedWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.cur.Sync(); err != nil {
+		return err
+	}
+	return w.index.Sync()
+}
+
+// Close closes the current segment and the index file.
+func (w *SegmentedWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.cur.Close(); err != nil {
+		return err
+	}
+	return w.index.Close()
+}
+
+// SegmentedReader reads records sequentially from a directory of segment
+// files written by a SegmentedWriter, transparently advancing to the next
+// segment once the current one is exhausted.
+type SegmentedReader struct {
+	dir     string
+	segment uint32
+	offset  int64
+	f       *os.File
+	r       *Reader
+}
+
+// NewSegmentedReader returns a new SegmentedReader starting at segment 0 of
+// dir.
+func NewSegmentedReader(dir string) (*SegmentedReader, error) {
+	r := &SegmentedReader{dir: dir}
+	if err := r.openSegment(0); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *SegmentedReader) openSegment(segment uint32) error {
+	f, err := os.Open(segmentPath(r.dir, segment))
+	if err != nil {
+		return err
+	}
+	if r.f != nil {
+		r.f.Close()
+	}
+	r.f = f
+	r.segment = segment
+	r.offset = 0
+	r.r = NewReader(f)
+	return nil
+}
+
+// Next returns the next record's type ID, data, and the RecordPos it was
+// read from, transparently spanning segment boundaries. It returns io.EOF
+// once the last segment is exhausted.
+func (r *SegmentedReader) Next() (uint16, []byte, RecordPos, error) {
+	for {
+		typeID, data, err := r.r.Next()
+		if err == io.EOF {
+			if _, statErr := os.Stat(segmentPath(r.dir, r.segment+1)); statErr != nil {
+				return 0, nil, RecordPos{}, io.EOF
+			}
+			if err := r.openSegment(r.segment + 1); err != nil {
+				return 0, nil, RecordPos{}, err
+			}
+			continue
+		}
+		if err != nil {
+			return 0, nil, RecordPos{}, err
+		}
+		pos := RecordPos{Segment: r.segment, Offset: r.offset}
+		r.offset += int64(10 + len(data))
+		return typeID, data, pos, nil
+	}
+}
+
+// Truncate discards pos and every record after it: the segment containing
+// pos is truncated to pos.Offset and any later segment files are removed
+// entirely. This is how a corrupted or partially-written tail, which the
+// CRC-only format otherwise can't safely discard, is dropped.
+func (r *SegmentedReader) Truncate(pos RecordPos) error {
+	if err := os.Truncate(segmentPath(r.dir, pos.Segment), pos.Offset); err != nil {
+		return fmt.Errorf("truncating segment %06d: %w", pos.Segment, err)
+	}
+	for segment := pos.Segment + 1; ; segment++ {
+		path := segmentPath(r.dir, segment)
+		if _, err := os.Stat(path); err != nil {
+			break
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("removing segment %06d: %w", segment, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the currently open segment file.
+func (r *SegmentedReader) Close() error {
+	return r.f.Close()
+}
+
+// RandomAccessReader reads individual records directly at a known
+// RecordPos, without rescanning the segments that precede it, analogous to
+// the position-based lookups used by WAL implementations such as etcd's.
+type RandomAccessReader struct {
+	dir string
+
+	mu    sync.Mutex
+	files map[uint32]*os.File
+}
+
+// NewRandomAccessReader returns a new RandomAccessReader over the segments
+// in dir.
+func NewRandomAccessReader(dir string) *RandomAccessReader {
+	return &RandomAccessReader{
+		dir:   dir,
+		files: make(map[uint32]*os.File),
+	}
+}
+
+func (r *RandomAccessReader) segmentFile(segment uint32) (*os.File, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.files[segment]; ok {
+		return f, nil
+	}
+	f, err := os.Open(segmentPath(r.dir, segment))
+	if err != nil {
+		return nil, err
+	}
+	r.files[segment] = f
+	return f, nil
+}
+
+// ReadAt reads the single record at pos using io.ReaderAt, without
+// rescanning any records before it.
+func (r *RandomAccessReader) ReadAt(pos RecordPos) (uint16, []byte, error) {
+	f, err := r.segmentFile(pos.Segment)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	hdrbuf := make([]byte, 10)
+	if _, err := f.ReadAt(hdrbuf, pos.Offset); err != nil {
+		return 0, nil, err
+	}
+	numBytes := binary.LittleEndian.Uint32(hdrbuf[0:4])
+	checksum := binary.LittleEndian.Uint32(hdrbuf[4:8])
+	typeID := binary.LittleEndian.Uint16(hdrbuf[8:10])
+
+	data := make([]byte, numBytes)
+	if _, err := f.ReadAt(data, pos.Offset+10); err != nil {
+		return 0, nil, err
+	}
+	if crc32.Checksum(data, checksumTable) != checksum {
+		return 0, nil, ErrBadChecksum
+	}
+	return typeID, data, nil
+}
+
+// Close closes every segment file opened by ReadAt.
+func (r *RandomAccessReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var firstErr error
+	for _, f := range r.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// ReadIndex reads the on-disk index file in dir, returning the RecordPos of
+// every record in the order it was written. It lets a process recover the
+// positions written by a prior SegmentedWriter without rescanning segments.
+func ReadIndex(dir string) ([]RecordPos, error) {
+	data, err := os.ReadFile(filepath.Join(dir, indexFileName))
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%indexEntrySize != 0 {
+		return nil, fmt.Errorf("index file size %d is not a multiple of %d", len(data), indexEntrySize)
+	}
+	positions := make([]RecordPos, 0, len(data)/indexEntrySize)
+	for offset := 0; offset < len(data); offset += indexEntrySize {
+		entry := data[offset : offset+indexEntrySize]
+		positions = append(positions, RecordPos{
+			Segment: binary.LittleEndian.Uint32(entry[0:4]),
+			Offset:  int64(binary.LittleEndian.Uint64(entry[4:12])),
+		})
+	}
+	return positions, nil
+}
@@ -0,0 +1,178 @@
+package protolog
+
+import (
+	"io"
+	"testing"
+)
+
+func TestSegmentedRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewSegmentedWriter(dir, 0, 2) // rotate every 2 records
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var positions []RecordPos
+	testValues := []string{"first", "second", "third", "fourth", "fifth"}
+	for _, val := range testValues {
+		pos, err := w.Write(FooTypeID, []byte(val))
+		if err != nil {
+			t.Fatalf("unexpected error %v for value %q", err, val)
+		}
+		positions = append(positions, pos)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing writer: %v", err)
+	}
+
+	if positions[0].Segment != 0 || positions[2].Segment != 1 || positions[4].Segment != 2 {
+		t.Fatalf("unexpected segment rotation: %+v", positions)
+	}
+
+	r, err := NewSegmentedReader(dir)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer r.Close()
+
+	for i, expected := range testValues {
+		typeID, data, pos, err := r.Next()
+		if err != nil {
+			t.Fatalf("unexpected error %v for value %q", err, expected)
+		}
+		if typeID != FooTypeID {
+			t.Fatalf("expected type ID %d, got %d", FooTypeID, typeID)
+		}
+		if string(data) != expected {
+			t.Fatalf("expected %q, got %q", expected, data)
+		}
+		if pos != positions[i] {
+			t.Fatalf("expected pos %+v, got %+v", positions[i], pos)
+		}
+	}
+	if _, _, _, err := r.Next(); err != io.EOF {
+		t.Fatalf("got %v, expected %v", err, io.EOF)
+	}
+}
+
+func TestRandomAccessReader(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewSegmentedWriter(dir, 0, 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	testValues := []string{"first", "second", "third"}
+	var positions []RecordPos
+	for _, val := range testValues {
+		pos, err := w.Write(BazTypeID, []byte(val))
+		if err != nil {
+			t.Fatalf("unexpected error %v for value %q", err, val)
+		}
+		positions = append(positions, pos)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing writer: %v", err)
+	}
+
+	ra := NewRandomAccessReader(dir)
+	defer ra.Close()
+
+	// Read out of order to confirm no rescanning is required.
+	for _, i := range []int{2, 0, 1} {
+		typeID, data, err := ra.ReadAt(positions[i])
+		if err != nil {
+			t.Fatalf("unexpected error %v for index %d", err, i)
+		}
+		if typeID != BazTypeID {
+			t.Fatalf("expected type ID %d, got %d", BazTypeID, typeID)
+		}
+		if string(data) != testValues[i] {
+			t.Fatalf("expected %q, got %q", testValues[i], data)
+		}
+	}
+}
+
+func TestSegmentedTruncate(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewSegmentedWriter(dir, 0, 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	testValues := []string{"first", "second", "third"}
+	var positions []RecordPos
+	for _, val := range testValues {
+		pos, err := w.Write(FooTypeID, []byte(val))
+		if err != nil {
+			t.Fatalf("unexpected error %v for value %q", err, val)
+		}
+		positions = append(positions, pos)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing writer: %v", err)
+	}
+
+	r, err := NewSegmentedReader(dir)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := r.Truncate(positions[2]); err != nil {
+		t.Fatalf("unexpected error truncating: %v", err)
+	}
+	r.Close()
+
+	r, err = NewSegmentedReader(dir)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer r.Close()
+	for _, expected := range testValues[:2] {
+		_, data, _, err := r.Next()
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if string(data) != expected {
+			t.Fatalf("expected %q, got %q", expected, data)
+		}
+	}
+	if _, _, _, err := r.Next(); err != io.EOF {
+		t.Fatalf("got %v, expected %v", err, io.EOF)
+	}
+}
+
+func TestReadIndex(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewSegmentedWriter(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var positions []RecordPos
+	for _, val := range []string{"first", "second"} {
+		pos, err := w.Write(FooTypeID, []byte(val))
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		positions = append(positions, pos)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing writer: %v", err)
+	}
+
+	indexed, err := ReadIndex(dir)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(indexed) != len(positions) {
+		t.Fatalf("expected %d index entries, got %d", len(positions), len(indexed))
+	}
+	for i, pos := range positions {
+		if indexed[i] != pos {
+			t.Fatalf("expected index entry %+v, got %+v", pos, indexed[i])
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package protolog
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Syncer is implemented by an io.Writer that can force previously written
+// bytes to stable storage, such as *os.File.
+type Syncer interface {
+	Sync() error
+}
+
+type queuedRecord struct {
+	typeID uint16
+	data   []byte
+	n      int
+	err    error
+}
+
+// SyncWriterOption configures a SyncWriter constructed by NewSyncWriter.
+type SyncWriterOption func(*SyncWriter)
+
+// WithMaxBatchBytes flushes the current batch immediately once its queued
+// records reach this many bytes. The default, zero, means no byte-based
+// limit.
+func WithMaxBatchBytes(n int) SyncWriterOption {
+	return func(w *SyncWriter) { w.maxBatchBytes = n }
+}
+
+// WithMaxBatchDelay sets the longest a batch accumulates before it's
+// flushed. The default, zero, flushes every batch as soon as the
+// background goroutine notices it's non-empty, which gives callers the
+// lowest latency but the least batching.
+func WithMaxBatchDelay(d time.Duration) SyncWriterOption {
+	return func(w *SyncWriter) { w.maxBatchDelay = d }
+}
+
+// SyncWriter makes concurrent Write calls from multiple goroutines safe by
+// group-committing them: records are queued in memory and a single
+// background goroutine appends each batch to the underlying writer
+// contiguously, fsync'ing it once per batch (if the writer implements
+// Syncer) before unblocking every caller whose record just became durable.
+// This is the group-commit pattern used by WAL implementations such as
+// etcd's and Prometheus's remote-write batching, and lets protolog accept
+// concurrent producers without external locking.
+//
+// maxBatchBytes and maxBatchDelay are fixed at construction time, before
+// the background goroutine starts, so Write and run can read them without
+// synchronization; unlike the rest of a SyncWriter's state, they are not
+// safe to change after NewSyncWriter returns.
+type SyncWriter struct {
+	w             io.Writer
+	maxBatchBytes int
+	maxBatchDelay time.Duration
+
+	mu       sync.Mutex
+	pending  []*queuedRecord
+	batchLen int           // bytes queued in the current batch, headers included
+	done     chan struct{} // closed and replaced each time the current batch is flushed
+
+	flushNow chan struct{}
+	closeC   chan struct{}
+	closed   chan struct{}
+}
+
+// NewSyncWriter returns a SyncWriter that group-commits records to w.
+func NewSyncWriter(w io.Writer, opts ...SyncWriterOption) *SyncWriter {
+	sw := &SyncWriter{
+		w:        w,
+		done:     make(chan struct{}),
+		flushNow: make(chan struct{}, 1),
+		closeC:   make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(sw)
+	}
+	go sw.run()
+	return sw
+}
+
+// Write queues a record for the next batch and blocks until that batch has
+// been written, and fsync'd if the underlying writer is a Syncer.
+func (w *SyncWriter) Write(typeID uint16, data []byte) (int, error) {
+	rec := &queuedRecord{typeID: typeID, data: data}
+
+	w.mu.Lock()
+	w.pending = append(w.pending, rec)
+	w.batchLen += 10 + len(data)
+	batchDone := w.done
+	full := w.maxBatchBytes > 0 && w.batchLen >= w.maxBatchBytes
+	w.mu.Unlock()
+
+	if full || w.maxBatchDelay <= 0 {
+		w.requestFlush()
+	}
+
+	<-batchDone
+	return rec.n, rec.err
+}
+
+// Flush forces the current batch to be written immediately, without
+// waiting for the WithMaxBatchDelay interval, and blocks until it has
+// completed.
+func (w *SyncWriter) Flush() error {
+	w.mu.Lock()
+	if len(w.pending) == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	batchDone := w.done
+	w.mu.Unlock()
+
+	w.requestFlush()
+	<-batchDone
+	return nil
+}
+
+func (w *SyncWriter) requestFlush() {
+	select {
+	case w.flushNow <- struct{}{}:
+	default:
+	}
+}
+
+// Close flushes any queued records and stops the background goroutine.
+func (w *SyncWriter) Close() error {
+	close(w.closeC)
+	<-w.closed
+	return nil
+}
+
+func (w *SyncWriter) run() {
+	defer close(w.closed)
+
+	var timerC <-chan time.Time
+	if w.maxBatchDelay > 0 {
+		timerC = time.After(w.maxBatchDelay)
+	}
+
+	for {
+		select {
+		case <-w.flushNow:
+			w.flushBatch()
+		case <-timerC:
+			w.flushBatch()
+			if w.maxBatchDelay > 0 {
+				timerC = time.After(w.maxBatchDelay)
+			}
+		case <-w.closeC:
+			w.flushBatch()
+			return
+		}
+	}
+}
+
+func (w *SyncWriter) flushBatch() {
+	w.mu.Lock()
+	batch := w.pending
+	batchDone := w.done
+	if len(batch) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	w.pending = nil
+	w.batchLen = 0
+	w.done = make(chan struct{})
+	w.mu.Unlock()
+
+	var firstErr error
+	for _, rec := range batch {
+		rec.n, rec.err = writeRecord(w.w, rec.typeID, rec.data)
+		if rec.err != nil && firstErr == nil {
+			firstErr = rec.err
+		}
+	}
+	if firstErr == nil {
+		if syncer, ok := w.w.(Syncer); ok {
+			if err := syncer.Sync(); err != nil {
+				for _, rec := range batch {
+					if rec.err == nil {
+						rec.err = err
+					}
+				}
+			}
+		}
+	}
+	close(batchDone)
+}
@@ -0,0 +1,131 @@
+package protolog
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncCountingBuffer wraps a bytes.Buffer with a Sync method so it
+// satisfies Syncer, and counts how many times Sync was called.
+type syncCountingBuffer struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	syncs int
+}
+
+func (b *syncCountingBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncCountingBuffer) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.syncs++
+	return nil
+}
+
+func TestSyncWriterConcurrentWrites(t *testing.T) {
+	buf := &syncCountingBuffer{}
+	w := NewSyncWriter(buf)
+
+	const n = 200
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := w.Write(uint16(i%3), []byte(fmt.Sprintf("record-%d", i))); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing writer: %v", err)
+	}
+
+	got := map[string]bool{}
+	r := NewReader(bytes.NewReader(buf.buf.Bytes()))
+	for {
+		_, data, err := r.Next()
+		if err != nil {
+			break
+		}
+		got[string(data)] = true
+	}
+	if len(got) != n {
+		t.Fatalf("expected %d distinct records, got %d", n, len(got))
+	}
+	for i := 0; i < n; i++ {
+		want := fmt.Sprintf("record-%d", i)
+		if !got[want] {
+			t.Fatalf("missing record %q", want)
+		}
+	}
+	if buf.syncs == 0 {
+		t.Fatalf("expected at least one Sync call")
+	}
+}
+
+func TestSyncWriterFlush(t *testing.T) {
+	buf := &syncCountingBuffer{}
+	w := NewSyncWriter(buf, WithMaxBatchDelay(time.Hour)) // would never flush on its own within the test
+
+	done := make(chan struct{})
+	go func() {
+		if _, err := w.Write(FooTypeID, []byte("hello")); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+		close(done)
+	}()
+
+	// Give the writer goroutine a chance to enqueue before we force a flush.
+	time.Sleep(10 * time.Millisecond)
+	if err := w.Flush(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Write did not return after Flush")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing writer: %v", err)
+	}
+
+	r := NewReader(bytes.NewReader(buf.buf.Bytes()))
+	typeID, data, err := r.Next()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if typeID != FooTypeID || string(data) != "hello" {
+		t.Fatalf("got (%d, %q), expected (%d, %q)", typeID, data, FooTypeID, "hello")
+	}
+}
+
+func TestSyncWriterMaxBatchBytes(t *testing.T) {
+	buf := &syncCountingBuffer{}
+	w := NewSyncWriter(buf, WithMaxBatchDelay(time.Hour), WithMaxBatchBytes(1)) // flush as soon as anything is queued
+
+	if _, err := w.Write(FooTypeID, []byte("hi")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error closing writer: %v", err)
+	}
+
+	r := NewReader(bytes.NewReader(buf.buf.Bytes()))
+	_, data, err := r.Next()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(data) != "hi" {
+		t.Fatalf("expected %q, got %q", "hi", data)
+	}
+}
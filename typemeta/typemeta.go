@@ -0,0 +1,105 @@
+// Package typemeta implements the type-descriptor catalog embedded in a
+// protolog file's preamble: a mapping from the 16-bit type IDs used in
+// record headers to the fully-qualified protobuf message name registered
+// for that ID, and optionally the FileDescriptorProto needed to build a
+// protoreflect.MessageType for dynamic unmarshaling.
+package typemeta
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type entry struct {
+	name string
+	fd   *descriptorpb.FileDescriptorProto
+}
+
+// Table maps type IDs to the protobuf message registered for them.
+type Table struct {
+	entries map[uint16]entry
+}
+
+// NewTable returns an empty type-descriptor table.
+func NewTable() *Table {
+	return &Table{entries: make(map[uint16]entry)}
+}
+
+// Register associates typeID with msg's fully-qualified message name, and
+// with msg's FileDescriptorProto if its descriptor is available, so that a
+// reader can later resolve a protoreflect.MessageType for typeID via
+// Descriptor. Descriptor resolves only top-level message types; for a
+// nested message type it returns false.
+func (t *Table) Register(typeID uint16, msg proto.Message) {
+	md := msg.ProtoReflect().Descriptor()
+	e := entry{name: string(md.FullName())}
+	if file := md.ParentFile(); file != nil {
+		e.fd = protodesc.ToFileDescriptorProto(file)
+	}
+	t.entries[typeID] = e
+}
+
+// Name returns the fully-qualified message name registered for typeID.
+func (t *Table) Name(typeID uint16) (string, bool) {
+	e, ok := t.entries[typeID]
+	return e.name, ok
+}
+
+// Descriptor returns the protoreflect.MessageType registered for typeID,
+// rebuilt from the embedded FileDescriptorProto, so that downstream code
+// can dynamically unmarshal a record's payload without a hard-coded type
+// registry. It returns false if typeID wasn't registered or was registered
+// without a FileDescriptorProto. The message is looked up by the last
+// segment of its name among the file's top-level messages only, so a
+// nested message type also yields false.
+func (t *Table) Descriptor(typeID uint16) (protoreflect.MessageType, bool) {
+	e, ok := t.entries[typeID]
+	if !ok || e.fd == nil {
+		return nil, false
+	}
+	file, err := protodesc.NewFile(e.fd, protoregistry.GlobalFiles)
+	if err != nil {
+		return nil, false
+	}
+	md := file.Messages().ByName(protoreflect.Name(lastSegment(e.name)))
+	if md == nil {
+		return nil, false
+	}
+	return dynamicpb.NewMessageType(md), true
+}
+
+func lastSegment(fullName string) string {
+	for i := len(fullName) - 1; i >= 0; i-- {
+		if fullName[i] == '.' {
+			return fullName[i+1:]
+		}
+	}
+	return fullName
+}
+
+// Entry is a single (typeID, name, FileDescriptorProto) tuple from a Table,
+// as serialized into or parsed back out of a file preamble.
+type Entry struct {
+	TypeID uint16
+	Name   string
+	FD     *descriptorpb.FileDescriptorProto
+}
+
+// Entries returns the table's entries in no particular order.
+func (t *Table) Entries() []Entry {
+	out := make([]Entry, 0, len(t.entries))
+	for typeID, e := range t.entries {
+		out = append(out, Entry{TypeID: typeID, Name: e.name, FD: e.fd})
+	}
+	return out
+}
+
+// AddEntry records a single (typeID, name, FileDescriptorProto) tuple, as
+// parsed back out of a file preamble.
+func (t *Table) AddEntry(typeID uint16, name string, fd *descriptorpb.FileDescriptorProto) {
+	t.entries[typeID] = entry{name: name, fd: fd}
+}